@@ -0,0 +1,130 @@
+package federation
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"opentela/internal/protocol"
+)
+
+// RegisterHandlers wires the federation HTTP endpoints onto mux, rooted at
+// /v1/federation/:
+//
+//   - POST /v1/federation/peerings       initiate a peering, generating its token
+//   - GET  /v1/federation/peerings       list established peerings
+//   - GET  /v1/federation/peerings/{name} read a single peering
+//   - GET  /v1/federation/inventory?peering={name} this server's exported inventory
+func (m *Manager) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/federation/peerings", m.handlePeerings)
+	mux.HandleFunc("/v1/federation/peerings/", m.handlePeering)
+	mux.HandleFunc("/v1/federation/inventory", m.handleInventory)
+}
+
+type initiateRequest struct {
+	Name             string   `json:"name"`
+	RemoteAddr       string   `json:"remote_addr"`
+	ExportedServices []string `json:"exported_services,omitempty"`
+}
+
+func (m *Manager) handlePeerings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		peerings := m.List()
+		views := make([]peeringView, len(peerings))
+		for i, p := range peerings {
+			views[i] = sanitizePeering(p)
+		}
+		writeJSON(w, http.StatusOK, views)
+	case http.MethodPost:
+		var req initiateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p, err := m.Initiate(req.Name, req.RemoteAddr, req.ExportedServices)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, p)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (m *Manager) handlePeering(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/federation/peerings/")
+	if name == "" {
+		http.NotFound(w, r)
+		return
+	}
+	p, ok := m.Get(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, sanitizePeering(p))
+}
+
+// peeringView is what handlePeerings/handlePeering serialize: a Peering
+// with Token omitted. Token is the bearer credential handleInventory
+// checks, so it must only ever reach the importing side once, in the
+// Initiate POST response that creates it; serving it back out of List/Get
+// would let anyone who can read the peerings list also read its
+// inventory.
+type peeringView struct {
+	Name             string   `json:"name"`
+	RemoteAddr       string   `json:"remote_addr"`
+	ExportedServices []string `json:"exported_services,omitempty"`
+}
+
+func sanitizePeering(p *Peering) peeringView {
+	return peeringView{
+		Name:             p.Name,
+		RemoteAddr:       p.RemoteAddr,
+		ExportedServices: p.ExportedServices,
+	}
+}
+
+// handleInventory serves this server's exported peer inventory for the
+// named peering; a remote server's reconciler polls this to learn about
+// our peers. The caller must present the peering's own token as a bearer
+// credential, the same one Reconcile sends, so only the paired remote can
+// read it.
+func (m *Manager) handleInventory(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("peering")
+	peering, ok := m.Get(name)
+	if !ok {
+		http.Error(w, "unknown peering", http.StatusNotFound)
+		return
+	}
+	if !bearerTokenMatches(r, peering.Token) {
+		http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+
+	var local []protocol.Peer
+	if m.LocalInventory != nil {
+		local = m.LocalInventory()
+	}
+	writeJSON(w, http.StatusOK, exportedInventory(local, peering))
+}
+
+// bearerTokenMatches reports whether r's Authorization header carries the
+// expected bearer token.
+func bearerTokenMatches(r *http.Request, token string) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
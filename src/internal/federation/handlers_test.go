@@ -0,0 +1,66 @@
+package federation
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startTestServer(t *testing.T) (*Manager, *httptest.Server) {
+	t.Helper()
+	mgr := NewManager()
+	mux := http.NewServeMux()
+	mgr.RegisterHandlers(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return mgr, srv
+}
+
+func TestHandlePeerings_List_OmitsToken(t *testing.T) {
+	mgr, srv := startTestServer(t)
+	_, err := mgr.Initiate("cluster-a", "http://a.example", nil)
+	require.NoError(t, err)
+
+	resp, err := http.Get(srv.URL + "/v1/federation/peerings")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "token")
+}
+
+func TestHandlePeering_Get_OmitsToken(t *testing.T) {
+	mgr, srv := startTestServer(t)
+	_, err := mgr.Initiate("cluster-a", "http://a.example", nil)
+	require.NoError(t, err)
+
+	resp, err := http.Get(srv.URL + "/v1/federation/peerings/cluster-a")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "token")
+}
+
+func TestHandlePeerings_Post_ReturnsToken(t *testing.T) {
+	_, srv := startTestServer(t)
+
+	resp, err := http.Post(srv.URL+"/v1/federation/peerings", "application/json",
+		strings.NewReader(`{"name":"cluster-a","remote_addr":"http://a.example"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"token"`)
+}
@@ -0,0 +1,162 @@
+// Package federation lets a local OpenFabric server pair with a remote
+// server ("peering") and exchange a subset of their protocol.Peer
+// inventories, so the server package's selectCandidates can fall back to
+// federated peers once local candidates are exhausted.
+package federation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"opentela/internal/protocol"
+)
+
+// Peering describes one established pairing with a remote OpenFabric
+// server.
+type Peering struct {
+	Name             string   `json:"name"`
+	Token            string   `json:"token"`
+	RemoteAddr       string   `json:"remote_addr"`
+	ExportedServices []string `json:"exported_services,omitempty"`
+}
+
+// tokenBytes is the size of a generated peering token, before hex
+// encoding.
+const tokenBytes = 32
+
+// GenerateToken returns a random hex-encoded token suitable for
+// authenticating a peering handshake.
+func GenerateToken() (string, error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("federation: generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Manager tracks this server's established peerings, the remote peer
+// inventory each one last reported, and how to produce this server's own
+// inventory for peers that poll it in turn.
+type Manager struct {
+	// LocalInventory returns this server's own peers, used to answer the
+	// /v1/federation/inventory endpoint. Left nil, the endpoint reports an
+	// empty inventory.
+	LocalInventory func() []protocol.Peer
+
+	mu       sync.RWMutex
+	peerings map[string]*Peering
+	remote   map[string][]protocol.Peer // peering name -> its last-known inventory
+}
+
+// NewManager returns an empty Manager ready to accept peerings.
+func NewManager() *Manager {
+	return &Manager{
+		peerings: make(map[string]*Peering),
+		remote:   make(map[string][]protocol.Peer),
+	}
+}
+
+// Initiate establishes a new peering with a freshly generated token and
+// records it. This is the importing side's half of the handshake: it
+// generates the token, which is shared with the remote cluster
+// out-of-band so the remote can call AcceptToken with that same value, and
+// Reconcile then presents it as a bearer credential when polling the
+// remote's inventory endpoint. Initiating a peering with a name that
+// already exists replaces the previous one.
+func (m *Manager) Initiate(name, remoteAddr string, exportedServices []string) (*Peering, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	return m.AcceptToken(name, token, remoteAddr, exportedServices), nil
+}
+
+// AcceptToken establishes a peering using a token generated by the remote
+// side's Initiate call, rather than generating a new one. This is the
+// exporting side's half of the handshake: its inventory endpoint verifies
+// the bearer token the importing side presents against the token recorded
+// here, and serves only the services listed in exportedServices.
+func (m *Manager) AcceptToken(name, token, remoteAddr string, exportedServices []string) *Peering {
+	p := &Peering{Name: name, Token: token, RemoteAddr: remoteAddr, ExportedServices: exportedServices}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.peerings[name] = p
+	return p
+}
+
+// Get returns the named peering, if any.
+func (m *Manager) Get(name string) (*Peering, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p, ok := m.peerings[name]
+	return p, ok
+}
+
+// List returns every established peering.
+func (m *Manager) List() []*Peering {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]*Peering, 0, len(m.peerings))
+	for _, p := range m.peerings {
+		out = append(out, p)
+	}
+	return out
+}
+
+// UpdateInventory replaces the cached remote inventory for a peering, as
+// reported by its reconciler. Each peer is tagged with Origin=name so
+// selectCandidates can demote it relative to local peers.
+func (m *Manager) UpdateInventory(name string, peers []protocol.Peer) {
+	tagged := make([]protocol.Peer, len(peers))
+	for i, p := range peers {
+		p.Origin = name
+		tagged[i] = p
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remote[name] = tagged
+}
+
+// FederatedPeers returns every peer advertised by any established
+// peering, each tagged with its peering's name in Origin. Callers merge
+// this with their own local inventory before calling selectCandidates.
+func (m *Manager) FederatedPeers() []protocol.Peer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []protocol.Peer
+	for _, peers := range m.remote {
+		out = append(out, peers...)
+	}
+	return out
+}
+
+// exportedInventory filters peers down to the services a peering is
+// allowed to advertise remotely. An empty ExportedServices exports
+// everything.
+func exportedInventory(peers []protocol.Peer, peering *Peering) []protocol.Peer {
+	if len(peering.ExportedServices) == 0 {
+		return peers
+	}
+	allowed := make(map[string]bool, len(peering.ExportedServices))
+	for _, name := range peering.ExportedServices {
+		allowed[name] = true
+	}
+
+	out := make([]protocol.Peer, 0, len(peers))
+	for _, p := range peers {
+		var services []protocol.Service
+		for _, svc := range p.Service {
+			if allowed[svc.Name] {
+				services = append(services, svc)
+			}
+		}
+		if len(services) > 0 {
+			out = append(out, protocol.Peer{ID: p.ID, Service: services})
+		}
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package federation
+
+import (
+	"testing"
+
+	"opentela/internal/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_Initiate_GeneratesUniqueToken(t *testing.T) {
+	m := NewManager()
+	a, err := m.Initiate("cluster-a", "http://a.example", nil)
+	require.NoError(t, err)
+	b, err := m.Initiate("cluster-b", "http://b.example", nil)
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, a.Token)
+	assert.NotEqual(t, a.Token, b.Token)
+}
+
+func TestManager_AcceptToken_UsesGivenToken(t *testing.T) {
+	m := NewManager()
+	p := m.AcceptToken("cluster-a", "shared-token", "http://a.example", nil)
+
+	assert.Equal(t, "shared-token", p.Token)
+	got, ok := m.Get("cluster-a")
+	require.True(t, ok)
+	assert.Equal(t, "shared-token", got.Token)
+}
+
+func TestManager_Get_UnknownPeering(t *testing.T) {
+	m := NewManager()
+	_, ok := m.Get("does-not-exist")
+	assert.False(t, ok)
+}
+
+func TestManager_List_ReturnsAllPeerings(t *testing.T) {
+	m := NewManager()
+	_, err := m.Initiate("cluster-a", "http://a.example", nil)
+	require.NoError(t, err)
+	_, err = m.Initiate("cluster-b", "http://b.example", nil)
+	require.NoError(t, err)
+
+	assert.Len(t, m.List(), 2)
+}
+
+func TestManager_UpdateInventory_TagsOrigin(t *testing.T) {
+	m := NewManager()
+	m.UpdateInventory("cluster-a", []protocol.Peer{{ID: "peer-1"}})
+
+	got := m.FederatedPeers()
+	require.Len(t, got, 1)
+	assert.Equal(t, "cluster-a", got[0].Origin)
+}
+
+func TestExportedInventory_EmptyExportList_ExportsEverything(t *testing.T) {
+	peers := []protocol.Peer{{ID: "peer-1", Service: []protocol.Service{{Name: "llm"}}}}
+	got := exportedInventory(peers, &Peering{})
+	assert.Equal(t, peers, got)
+}
+
+func TestExportedInventory_FiltersToAllowedServices(t *testing.T) {
+	peers := []protocol.Peer{
+		{ID: "peer-1", Service: []protocol.Service{{Name: "llm"}, {Name: "vision"}}},
+	}
+	got := exportedInventory(peers, &Peering{ExportedServices: []string{"llm"}})
+	require.Len(t, got, 1)
+	require.Len(t, got[0].Service, 1)
+	assert.Equal(t, "llm", got[0].Service[0].Name)
+}
+
+func TestExportedInventory_DropsPeersWithNoExportedServices(t *testing.T) {
+	peers := []protocol.Peer{
+		{ID: "peer-1", Service: []protocol.Service{{Name: "vision"}}},
+	}
+	got := exportedInventory(peers, &Peering{ExportedServices: []string{"llm"}})
+	assert.Empty(t, got)
+}
@@ -0,0 +1,73 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"opentela/internal/protocol"
+)
+
+// pollInterval is how often the reconciler re-fetches a peering's remote
+// inventory between round trips. A true long-poll would have the remote
+// hold the connection open until its inventory changes; until the wire
+// protocol grows that, a short poll interval gets equivalent freshness at
+// the cost of a few extra idle requests.
+const pollInterval = 5 * time.Second
+
+// Reconcile runs until ctx is canceled, periodically fetching the remote
+// server's advertised inventory for peering and feeding it into
+// m.UpdateInventory. It's meant to run as its own goroutine per
+// established peering.
+func (m *Manager) Reconcile(ctx context.Context, peering *Peering, client *http.Client) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	m.poll(ctx, peering, client)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.poll(ctx, peering, client)
+		}
+	}
+}
+
+func (m *Manager) poll(ctx context.Context, peering *Peering, client *http.Client) {
+	peers, err := fetchInventory(ctx, client, peering)
+	if err != nil {
+		return
+	}
+	m.UpdateInventory(peering.Name, peers)
+}
+
+func fetchInventory(ctx context.Context, client *http.Client, peering *Peering) ([]protocol.Peer, error) {
+	url := fmt.Sprintf("%s/v1/federation/inventory?peering=%s", peering.RemoteAddr, peering.Name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+peering.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("federation: inventory fetch for peering %q failed: %s", peering.Name, resp.Status)
+	}
+
+	var peers []protocol.Peer
+	if err := json.NewDecoder(resp.Body).Decode(&peers); err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
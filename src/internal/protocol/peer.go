@@ -0,0 +1,26 @@
+// Package protocol defines the wire-level types shared between OpenFabric
+// servers and the peers (local processes or remote nodes) that advertise
+// services to them.
+package protocol
+
+// Service describes a single capability a peer offers, e.g. "llm" or
+// "vision". IdentityGroup entries are the raw, unparsed predicate strings a
+// peer advertises to describe which requests it is willing to serve; see
+// the server package for how they are parsed and matched.
+type Service struct {
+	Name          string   `json:"name"`
+	IdentityGroup []string `json:"identity_group,omitempty"`
+}
+
+// Peer is a single provider known to a server, along with the services it
+// advertises.
+type Peer struct {
+	ID      string    `json:"id"`
+	Service []Service `json:"service,omitempty"`
+
+	// Origin identifies where this peer was learned from: "" for a peer
+	// registered directly with this server, otherwise the name of the
+	// federation.Peering it was learned through. The router uses this to
+	// prefer local peers over federated ones within the same tier.
+	Origin string `json:"origin,omitempty"`
+}
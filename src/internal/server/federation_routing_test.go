@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"opentela/internal/federation"
+	"opentela/internal/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startFederationServer spins up an in-process HTTP server exposing the
+// federation endpoints for a Manager that reports local as its inventory.
+func startFederationServer(t *testing.T, local []protocol.Peer) (*federation.Manager, *httptest.Server) {
+	t.Helper()
+	mgr := federation.NewManager()
+	mgr.LocalInventory = func() []protocol.Peer { return local }
+
+	mux := http.NewServeMux()
+	mgr.RegisterHandlers(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return mgr, srv
+}
+
+func TestFederation_RemoteCandidates_DemotedOneFallbackLevel(t *testing.T) {
+	remotePeers := []protocol.Peer{
+		peer("remote-peer", svc("llm", "model=gpt4")),
+	}
+	remoteMgr, remoteSrv := startFederationServer(t, remotePeers)
+
+	localMgr, _ := startFederationServer(t, nil)
+	peering, err := localMgr.Initiate("remote-cluster", remoteSrv.URL, nil)
+	require.NoError(t, err)
+	remoteMgr.AcceptToken("remote-cluster", peering.Token, "", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	localMgr.Reconcile(ctx, peering, remoteSrv.Client())
+	cancel()
+
+	federated := localMgr.FederatedPeers()
+	require.Len(t, federated, 1)
+	assert.Equal(t, "remote-cluster", federated[0].Origin)
+
+	localPeers := []protocol.Peer{
+		peer("local-peer", svc("llm", "model=llama")),
+	}
+	body := []byte(`{"model":"gpt4"}`)
+	merged := append(append([]protocol.Peer{}, localPeers...), federated...)
+
+	// The remote peer offers an exact match, but federated peers are
+	// demoted a tier, so it only shows up once fallback reaches wildcard.
+	assert.Empty(t, selectCandidates(merged, "llm", body, fallbackExact))
+	assert.Equal(t, []string{"remote-peer"}, selectCandidates(merged, "llm", body, fallbackWildcard))
+}
+
+func TestFederation_LocalExactMatch_WinsOverFederatedExact(t *testing.T) {
+	remotePeers := []protocol.Peer{
+		peer("remote-peer", svc("llm", "model=gpt4")),
+	}
+	remoteMgr, remoteSrv := startFederationServer(t, remotePeers)
+
+	localMgr, _ := startFederationServer(t, nil)
+	peering, err := localMgr.Initiate("remote-cluster", remoteSrv.URL, nil)
+	require.NoError(t, err)
+	remoteMgr.AcceptToken("remote-cluster", peering.Token, "", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	localMgr.Reconcile(ctx, peering, remoteSrv.Client())
+	cancel()
+
+	localPeers := []protocol.Peer{
+		peer("local-peer", svc("llm", "model=gpt4")),
+	}
+	body := []byte(`{"model":"gpt4"}`)
+	merged := append(append([]protocol.Peer{}, localPeers...), localMgr.FederatedPeers()...)
+
+	// Both peers would be an exact match; the local one wins at fallback 0,
+	// and the federated one never surfaces even at higher fallback levels.
+	got := selectCandidates(merged, "llm", body, fallbackCatchAll)
+	assert.Equal(t, []string{"local-peer"}, got)
+}
+
+func TestFederation_LocalOnlyScope_ExcludesFederatedPeers(t *testing.T) {
+	federated := []protocol.Peer{
+		peer("remote-peer", svc("llm", "model=gpt4")),
+	}
+	federated[0].Origin = "remote-cluster"
+	merged := append([]protocol.Peer{peer("local-peer", svc("llm", "model=gpt4"))}, federated...)
+
+	scoped := scopedProviders(merged, "local_only")
+	assert.Len(t, scoped, 1)
+	assert.Equal(t, "local-peer", scoped[0].ID)
+
+	scoped = scopedProviders(merged, "all_peers")
+	assert.Len(t, scoped, 2)
+}
+
+func TestFederation_ExportedServices_FiltersInventory(t *testing.T) {
+	remotePeers := []protocol.Peer{
+		peer("remote-peer", svc("llm", "model=gpt4"), svc("vision", "all")),
+	}
+	remoteMgr, remoteSrv := startFederationServer(t, remotePeers)
+
+	localMgr, _ := startFederationServer(t, nil)
+	peering, err := localMgr.Initiate("remote-cluster", remoteSrv.URL, []string{"llm"})
+	require.NoError(t, err)
+	// The remote side also records the peering, with the same token, so
+	// it knows which services it agreed to export to us.
+	remoteMgr.AcceptToken("remote-cluster", peering.Token, "", []string{"llm"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	localMgr.Reconcile(ctx, peering, remoteSrv.Client())
+	cancel()
+
+	federated := localMgr.FederatedPeers()
+	require.Len(t, federated, 1)
+	require.Len(t, federated[0].Service, 1)
+	assert.Equal(t, "llm", federated[0].Service[0].Name)
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"container/list"
+	"net/netip"
+	"regexp"
+	"sync"
+)
+
+// matcherCacheCapacity bounds how many distinct raw predicate clauses the
+// compiled regex/CIDR cache holds before evicting the least recently used
+// entry. Peers churn, so unbounded growth would leak memory for
+// advertisements that are no longer in use.
+const matcherCacheCapacity = 4096
+
+// compiledMatcher holds whichever compiled form a cache entry represents;
+// only one of the two fields is populated for a given entry.
+type compiledMatcher struct {
+	regex  *regexp.Regexp
+	prefix netip.Prefix
+}
+
+type matcherCacheEntry struct {
+	key     string
+	matcher compiledMatcher
+	err     error
+}
+
+// matcherCache memoizes the compiled form of "~=" regex and "@=" CIDR
+// predicate clauses, keyed by the raw clause text they came from, so
+// repeated selectCandidates calls don't recompile them on every request.
+// It's shared process-wide, matching the rest of this package: there's no
+// per-tenant Server type today for it to live on instead.
+type matcherCache struct {
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	order    *list.List // front = most recently used
+	capacity int
+}
+
+func newMatcherCache(capacity int) *matcherCache {
+	return &matcherCache{
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		capacity: capacity,
+	}
+}
+
+var globalMatcherCache = newMatcherCache(matcherCacheCapacity)
+
+// compileRegex returns the compiled form of pattern, reusing a cached
+// compile for clause if one already exists.
+func (c *matcherCache) compileRegex(clause, pattern string) (*regexp.Regexp, error) {
+	if entry, ok := c.get(clause); ok {
+		return entry.matcher.regex, entry.err
+	}
+	re, err := regexp.Compile(pattern)
+	c.put(clause, compiledMatcher{regex: re}, err)
+	return re, err
+}
+
+// compilePrefix returns the parsed CIDR prefix for cidr, reusing a cached
+// parse for clause if one already exists.
+func (c *matcherCache) compilePrefix(clause, cidr string) (netip.Prefix, error) {
+	if entry, ok := c.get(clause); ok {
+		return entry.matcher.prefix, entry.err
+	}
+	p, err := netip.ParsePrefix(cidr)
+	c.put(clause, compiledMatcher{prefix: p}, err)
+	return p, err
+}
+
+// There is no invalidate method: entries are keyed by the raw clause text
+// itself, so the same clause always compiles to the same matcher and a
+// peer re-advertising it never needs to evict a stale entry. Peer churn is
+// instead bounded by the LRU eviction in put, so clauses no one advertises
+// anymore eventually age out on their own.
+
+func (c *matcherCache) get(clause string) (matcherCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[clause]
+	if !ok {
+		return matcherCacheEntry{}, false
+	}
+	c.order.MoveToFront(el)
+	return *el.Value.(*matcherCacheEntry), true
+}
+
+func (c *matcherCache) put(clause string, matcher compiledMatcher, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[clause]; ok {
+		entry := el.Value.(*matcherCacheEntry)
+		entry.matcher, entry.err = matcher, err
+		c.order.MoveToFront(el)
+		return
+	}
+
+	el := c.order.PushFront(&matcherCacheEntry{key: clause, matcher: matcher, err: err})
+	c.entries[clause] = el
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*matcherCacheEntry).key)
+	}
+}
@@ -0,0 +1,396 @@
+// Package server implements the OpenFabric request router: matching
+// inbound requests against the identity groups peers advertise for a
+// service, and picking which peer(s) are eligible to handle a request.
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"opentela/internal/protocol"
+)
+
+// Fallback levels, from most to least specific. A request is only routed
+// to a peer whose best-matching identity-group entry is at or below the
+// requested fallback level.
+const (
+	fallbackExact    = 0
+	fallbackWildcard = 1
+	fallbackCatchAll = 2
+)
+
+// parseFallbackLevel parses the X-Fallback-Level header (or equivalent)
+// into one of fallbackExact, fallbackWildcard or fallbackCatchAll. Any
+// value that isn't exactly "0", "1" or "2" defaults to fallbackExact, so
+// callers never have to special-case an empty or malformed header.
+func parseFallbackLevel(raw string) int {
+	switch raw {
+	case "1":
+		return fallbackWildcard
+	case "2":
+		return fallbackCatchAll
+	default:
+		return fallbackExact
+	}
+}
+
+// predicate is a single (jsonPath, op, value) triple parsed out of an
+// identity-group entry, e.g. "messages.0.role=system" -> path
+// ["messages", "0", "role"], op "=", value "system". For the "~=" and
+// "@=" operators, value's compiled form is resolved once at parse time
+// (via globalMatcherCache) and stashed here so matching never recompiles
+// it.
+type predicate struct {
+	path   []string
+	op     string
+	value  string
+	regex  *regexp.Regexp
+	prefix netip.Prefix
+}
+
+// predicateOps lists the recognized operators, longest/most-specific
+// first so that e.g. "!=" is matched before a bare "=", and "@="/"~=" are
+// matched before the "=" they contain.
+var predicateOps = []string{"!=", "<=", ">=", "=~", "~=", "@=", "=", "<", ">"}
+
+// stripSlashes reports the regex pattern inside a "/pattern/" literal, as
+// required by the "~=" operator's value grammar.
+func stripSlashes(value string) (string, bool) {
+	if len(value) < 2 || value[0] != '/' || value[len(value)-1] != '/' {
+		return "", false
+	}
+	return value[1 : len(value)-1], true
+}
+
+// parsePredicate splits a single predicate clause (no "&") into a
+// (path, op, value) triple. It returns ok=false for clauses that don't
+// contain a recognized operator, or whose "~=" / "@=" value fails to
+// compile, so callers can skip malformed entries instead of matching
+// them or panicking on them.
+func parsePredicate(clause string) (predicate, bool) {
+	for _, op := range predicateOps {
+		idx := strings.Index(clause, op)
+		if idx <= 0 {
+			continue
+		}
+		key := clause[:idx]
+		value := clause[idx+len(op):]
+		p := predicate{path: strings.Split(key, "."), op: op, value: value}
+
+		switch op {
+		case "=~":
+			re, err := globalMatcherCache.compileRegex(clause, value)
+			if err != nil {
+				return predicate{}, false
+			}
+			p.regex = re
+		case "~=":
+			pattern, ok := stripSlashes(value)
+			if !ok {
+				return predicate{}, false
+			}
+			re, err := globalMatcherCache.compileRegex(clause, pattern)
+			if err != nil {
+				return predicate{}, false
+			}
+			p.regex = re
+		case "@=":
+			prefix, err := globalMatcherCache.compilePrefix(clause, value)
+			if err != nil {
+				return predicate{}, false
+			}
+			p.prefix = prefix
+		}
+		return p, true
+	}
+	return predicate{}, false
+}
+
+// identityGroupEntry is a single parsed identity-group entry: a
+// conjunction of predicates plus the fallback tier it belongs to.
+type identityGroupEntry struct {
+	predicates []predicate
+	tier       int
+	catchAll   bool
+}
+
+// weightHintPrefix marks an identity-group entry as the WeightedSelector's
+// "weight=N" hint rather than a routing predicate. Such entries are
+// reserved out of the match space entirely: without this, "weight=5"
+// would also be parsed as the predicate $.weight == "5" and could win an
+// exact-tier match against any request body that happens to contain a
+// "weight" field.
+const weightHintPrefix = "weight="
+
+// parseIdentityGroupEntry parses one raw identity-group string, e.g.
+// "model=gpt4&messages.0.role=system&max_tokens<=4096". The special
+// literal "all" is the catch-all entry. Entries in which any clause is not
+// a valid predicate, and the "weight=N" selector hint, are rejected
+// (ok=false) so they're skipped rather than treated as an accidental
+// match, mirroring the old single-predicate behavior for malformed
+// entries such as "malformed-no-equals".
+func parseIdentityGroupEntry(raw string) (identityGroupEntry, bool) {
+	if raw == "all" {
+		return identityGroupEntry{tier: fallbackCatchAll, catchAll: true}, true
+	}
+	if strings.HasPrefix(raw, weightHintPrefix) {
+		return identityGroupEntry{}, false
+	}
+
+	clauses := strings.Split(raw, "&")
+	predicates := make([]predicate, 0, len(clauses))
+	tier := fallbackExact
+	for _, clause := range clauses {
+		p, ok := parsePredicate(clause)
+		if !ok {
+			return identityGroupEntry{}, false
+		}
+		switch p.op {
+		case "=~", "~=", "@=":
+			tier = fallbackWildcard
+		case "=":
+			if p.value == "*" {
+				tier = fallbackWildcard
+			}
+		}
+		predicates = append(predicates, p)
+	}
+	if len(predicates) == 0 {
+		return identityGroupEntry{}, false
+	}
+	return identityGroupEntry{predicates: predicates, tier: tier}, true
+}
+
+// resolveJSONPath walks doc (as produced by json.Unmarshal into any)
+// following path, which is a mix of object keys and integer array indices.
+// It reports ok=false if any segment is missing or doc isn't shaped the
+// way the path expects.
+func resolveJSONPath(doc any, path []string) (any, bool) {
+	cur := doc
+	for _, segment := range path {
+		switch node := cur.(type) {
+		case map[string]any:
+			v, exists := node[segment]
+			if !exists {
+				return nil, false
+			}
+			cur = v
+		case []any:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			cur = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// matchPredicate evaluates a single predicate against the decoded request
+// body. Missing keys and type mismatches are treated as a non-match rather
+// than an error, so a malformed or unexpected body never panics.
+func matchPredicate(p predicate, doc any) bool {
+	resolved, exists := resolveJSONPath(doc, p.path)
+
+	if p.op == "=" && p.value == "*" {
+		return exists
+	}
+	if !exists {
+		return false
+	}
+
+	switch p.op {
+	case "=":
+		return stringify(resolved) == p.value
+	case "!=":
+		return stringify(resolved) != p.value
+	case "=~":
+		return p.regex.MatchString(stringify(resolved))
+	case "~=":
+		return p.regex.MatchString(stringify(resolved))
+	case "@=":
+		addr, err := netip.ParseAddr(stringify(resolved))
+		if err != nil {
+			return false
+		}
+		return p.prefix.Contains(addr)
+	case "<", "<=", ">", ">=":
+		lhs, ok := asFloat(resolved)
+		if !ok {
+			return false
+		}
+		rhs, err := strconv.ParseFloat(p.value, 64)
+		if err != nil {
+			return false
+		}
+		switch p.op {
+		case "<":
+			return lhs < rhs
+		case "<=":
+			return lhs <= rhs
+		case ">":
+			return lhs > rhs
+		default:
+			return lhs >= rhs
+		}
+	default:
+		return false
+	}
+}
+
+// stringify renders a decoded JSON value the way it would appear in an
+// identity-group string, so values parsed through encoding/json can be
+// compared against the raw literals peers advertise.
+func stringify(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// asFloat extracts a numeric value for the comparison operators. Only
+// json.Number-compatible values (decoded as float64) are considered
+// comparable; anything else is a type mismatch.
+func asFloat(v any) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+// matchEntry reports whether every predicate in entry holds against body.
+// doc and docOK are the result of decodeBody, which the caller runs once
+// per request.
+func matchEntry(entry identityGroupEntry, doc any, docOK bool) bool {
+	if entry.catchAll {
+		return true
+	}
+	if !docOK {
+		return false
+	}
+	for _, p := range entry.predicates {
+		if !matchPredicate(p, doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeBody decodes a request body into a generic JSON value for path
+// resolution. An empty or nil body decodes to an empty object so catch-all
+// entries still match.
+func decodeBody(body []byte) (any, bool) {
+	if len(body) == 0 {
+		return map[string]any{}, true
+	}
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+// selectCandidates returns the IDs of peers eligible to serve a request for
+// service, given the raw JSON request body and the caller's fallback level.
+//
+// For each peer offering service, every identity-group entry is parsed and
+// matched against body; the peer's best (lowest-tier) matching entry
+// determines which tier the peer is recorded in. A peer only appears in
+// the result if its best tier is at or below fallbackLevel, and exact
+// matches are preferred over wildcard matches, which are preferred over
+// catch-all matches.
+//
+// Federated peers (non-empty protocol.Peer.Origin) are demoted one tier
+// below whatever their identity-group entries would otherwise earn them,
+// capped at fallbackCatchAll. Combined with byTier only ever returning the
+// best non-empty tier, this means a federated exact match is only surfaced
+// once local exact matches are exhausted, and likewise for the wildcard
+// tier; callers control whether federated peers are considered at all via
+// parsePeerScope before calling selectCandidates.
+func selectCandidates(providers []protocol.Peer, service string, body []byte, fallbackLevel int) []string {
+	doc, docOK := decodeBody(body)
+
+	byTier := make([][]string, fallbackCatchAll+1)
+	seen := make(map[string]bool)
+
+	for _, p := range providers {
+		bestTier := -1
+		for _, svc := range p.Service {
+			if svc.Name != service {
+				continue
+			}
+			for _, raw := range svc.IdentityGroup {
+				entry, ok := parseIdentityGroupEntry(raw)
+				if !ok {
+					continue
+				}
+				if !matchEntry(entry, doc, docOK) {
+					continue
+				}
+				if bestTier == -1 || entry.tier < bestTier {
+					bestTier = entry.tier
+				}
+			}
+		}
+		if bestTier == -1 {
+			continue
+		}
+		if p.Origin != "" && bestTier < fallbackCatchAll {
+			bestTier++
+		}
+		if bestTier > fallbackLevel || seen[p.ID] {
+			continue
+		}
+		seen[p.ID] = true
+		byTier[bestTier] = append(byTier[bestTier], p.ID)
+	}
+
+	for _, tier := range byTier {
+		if len(tier) > 0 {
+			return tier
+		}
+	}
+	return nil
+}
+
+// peerScopeHeaderLocalOnly is the value of the fallback-scope header
+// (e.g. X-Peer-Scope) that restricts routing to local peers only. Any
+// other value, including "all_peers" or an absent header, includes
+// federated peers in the candidate set.
+const peerScopeHeaderLocalOnly = "local_only"
+
+// parsePeerScope reports whether the given X-Peer-Scope header value
+// restricts a request to local peers, excluding any peer learned through
+// federation.
+func parsePeerScope(raw string) bool {
+	return raw == peerScopeHeaderLocalOnly
+}
+
+// scopedProviders filters providers down to local-only peers when the
+// caller requested peerScopeHeaderLocalOnly; otherwise it returns
+// providers unchanged, including any federated peers already merged into
+// it by the caller.
+func scopedProviders(providers []protocol.Peer, scopeHeader string) []protocol.Peer {
+	if !parsePeerScope(scopeHeader) {
+		return providers
+	}
+	local := make([]protocol.Peer, 0, len(providers))
+	for _, p := range providers {
+		if p.Origin == "" {
+			local = append(local, p)
+		}
+	}
+	return local
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // ---------------------------------------------------------------------------
@@ -279,3 +280,259 @@ func TestSelectCandidates_ProviderNotAddedTwice(t *testing.T) {
 	assert.Len(t, got, 1)
 	assert.Equal(t, "peer-a", got[0])
 }
+
+// ---------------------------------------------------------------------------
+// selectCandidates – conjunctions of nested-path predicates
+// ---------------------------------------------------------------------------
+
+func TestSelectCandidates_Conjunction_AllPredicatesMatch(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model=gpt4&messages.0.role=system&max_tokens<=4096")),
+	}
+	body := []byte(`{"model":"gpt4","messages":[{"role":"system"}],"max_tokens":2048}`)
+	got := selectCandidates(providers, "llm", body, 0)
+	assert.Equal(t, []string{"peer-a"}, got)
+}
+
+func TestSelectCandidates_Conjunction_OnePredicateFails_NoMatch(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model=gpt4&messages.0.role=system&max_tokens<=4096")),
+	}
+	// max_tokens exceeds the <=4096 bound, so the whole conjunction fails.
+	body := []byte(`{"model":"gpt4","messages":[{"role":"system"}],"max_tokens":8192}`)
+	got := selectCandidates(providers, "llm", body, 0)
+	assert.Empty(t, got)
+}
+
+func TestSelectCandidates_Conjunction_MissingNestedKey_NoMatch(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model=gpt4&messages.0.role=system")),
+	}
+	body := []byte(`{"model":"gpt4","messages":[]}`) // messages.0 doesn't exist
+	got := selectCandidates(providers, "llm", body, 0)
+	assert.Empty(t, got)
+}
+
+func TestSelectCandidates_Conjunction_TypeMismatch_NoMatch(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "max_tokens<=4096")),
+	}
+	// max_tokens is a string, not a number, so the numeric comparison can't run.
+	body := []byte(`{"max_tokens":"a lot"}`)
+	got := selectCandidates(providers, "llm", body, 0)
+	assert.Empty(t, got)
+}
+
+func TestSelectCandidates_Conjunction_NotEqualOperator(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model!=llama")),
+	}
+	body := []byte(`{"model":"gpt4"}`)
+	got := selectCandidates(providers, "llm", body, 0)
+	assert.Equal(t, []string{"peer-a"}, got)
+}
+
+func TestSelectCandidates_Conjunction_RegexOperatorDemotesToWildcardTier(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model=~^gpt-4.*")),
+	}
+	body := []byte(`{"model":"gpt-4-turbo"}`)
+	// Regex predicates live in the wildcard tier, so fallback level 0 rejects them.
+	assert.Empty(t, selectCandidates(providers, "llm", body, 0))
+	got := selectCandidates(providers, "llm", body, 1)
+	assert.Equal(t, []string{"peer-a"}, got)
+}
+
+func TestSelectCandidates_Conjunction_MalformedClause_EntrySkipped(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model=gpt4&not-a-predicate")),
+	}
+	body := []byte(`{"model":"gpt4"}`)
+	got := selectCandidates(providers, "llm", body, 0)
+	assert.Empty(t, got)
+}
+
+func TestSelectCandidates_Conjunction_MalformedRegex_EntrySkippedNotAlwaysFalse(t *testing.T) {
+	// An invalid "=~" pattern must be rejected at parse time like any
+	// other malformed entry, not silently kept around as a predicate that
+	// can never match.
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model=~[unterminated", "model=gpt4")),
+	}
+	body := []byte(`{"model":"gpt4"}`)
+	got := selectCandidates(providers, "llm", body, 2)
+	assert.Equal(t, []string{"peer-a"}, got)
+
+	_, ok := parseIdentityGroupEntry("model=~[unterminated")
+	assert.False(t, ok)
+}
+
+// ---------------------------------------------------------------------------
+// selectCandidates – "~=" regex and "@=" CIDR operators
+// ---------------------------------------------------------------------------
+
+func TestSelectCandidates_RegexOperator_MatchesAndDemotesToWildcardTier(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model~=/^gpt-4.*/")),
+	}
+	body := []byte(`{"model":"gpt-4-turbo"}`)
+	assert.Empty(t, selectCandidates(providers, "llm", body, fallbackExact))
+	got := selectCandidates(providers, "llm", body, fallbackWildcard)
+	assert.Equal(t, []string{"peer-a"}, got)
+}
+
+func TestSelectCandidates_RegexOperator_NoMatch(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model~=/^gpt-4.*/")),
+	}
+	body := []byte(`{"model":"llama-3"}`)
+	got := selectCandidates(providers, "llm", body, fallbackWildcard)
+	assert.Empty(t, got)
+}
+
+func TestSelectCandidates_RegexOperator_MalformedPattern_EntrySkippedNotPanicked(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model~=/[unterminated/")),
+	}
+	body := []byte(`{"model":"anything"}`)
+	assert.NotPanics(t, func() {
+		got := selectCandidates(providers, "llm", body, fallbackCatchAll)
+		assert.Empty(t, got)
+	})
+}
+
+func TestSelectCandidates_RegexOperator_MissingSlashes_EntrySkipped(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model~=^gpt-4.*")),
+	}
+	body := []byte(`{"model":"gpt-4-turbo"}`)
+	got := selectCandidates(providers, "llm", body, fallbackCatchAll)
+	assert.Empty(t, got)
+}
+
+func TestSelectCandidates_CIDROperator_MatchesAndDemotesToWildcardTier(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "client_ip@=10.0.0.0/8")),
+	}
+	body := []byte(`{"client_ip":"10.1.2.3"}`)
+	assert.Empty(t, selectCandidates(providers, "llm", body, fallbackExact))
+	got := selectCandidates(providers, "llm", body, fallbackWildcard)
+	assert.Equal(t, []string{"peer-a"}, got)
+}
+
+func TestSelectCandidates_CIDROperator_Mismatch_NoMatch(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "client_ip@=10.0.0.0/8")),
+	}
+	body := []byte(`{"client_ip":"192.168.1.1"}`)
+	got := selectCandidates(providers, "llm", body, fallbackCatchAll)
+	assert.Empty(t, got)
+}
+
+func TestSelectCandidates_CIDROperator_MalformedPrefix_EntrySkippedNotPanicked(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "client_ip@=not-a-cidr")),
+	}
+	body := []byte(`{"client_ip":"10.1.2.3"}`)
+	assert.NotPanics(t, func() {
+		got := selectCandidates(providers, "llm", body, fallbackCatchAll)
+		assert.Empty(t, got)
+	})
+}
+
+func TestSelectCandidates_CIDROperator_NonIPValue_NoMatch(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "client_ip@=10.0.0.0/8")),
+	}
+	body := []byte(`{"client_ip":"not-an-ip"}`)
+	got := selectCandidates(providers, "llm", body, fallbackCatchAll)
+	assert.Empty(t, got)
+}
+
+// ---------------------------------------------------------------------------
+// selectCandidates – "weight=N" hint is reserved out of the match space
+// ---------------------------------------------------------------------------
+
+func TestSelectCandidates_WeightHint_DoesNotLeakIntoMatching(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model=gpt4", "weight=5")),
+	}
+	// The request body happens to contain a "weight" field that equals
+	// the hint's value, but the hint must never be usable as a predicate.
+	body := []byte(`{"weight":5}`)
+	got := selectCandidates(providers, "llm", body, fallbackExact)
+	assert.Empty(t, got)
+}
+
+func TestSelectCandidates_WeightHint_ModelStillMatches(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model=gpt4", "weight=5")),
+	}
+	body := []byte(`{"model":"gpt4","weight":5}`)
+	got := selectCandidates(providers, "llm", body, fallbackExact)
+	assert.Equal(t, []string{"peer-a"}, got)
+}
+
+// ---------------------------------------------------------------------------
+// matcherCache – compile-once behavior
+// ---------------------------------------------------------------------------
+
+func TestMatcherCache_CompileRegex_CacheHitReturnsSameInstance(t *testing.T) {
+	cache := newMatcherCache(16)
+	clause := "model~=/^gpt-4.*/"
+
+	first, err := cache.compileRegex(clause, "^gpt-4.*")
+	require.NoError(t, err)
+	second, err := cache.compileRegex(clause, "^gpt-4.*")
+	require.NoError(t, err)
+
+	assert.Same(t, first, second)
+}
+
+func TestMatcherCache_CompilePrefix_CacheHit(t *testing.T) {
+	cache := newMatcherCache(16)
+	clause := "client_ip@=10.0.0.0/8"
+
+	first, err := cache.compilePrefix(clause, "10.0.0.0/8")
+	require.NoError(t, err)
+	second, err := cache.compilePrefix(clause, "10.0.0.0/8")
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+}
+
+func TestMatcherCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newMatcherCache(2)
+
+	_, err := cache.compileRegex("a~=/a/", "a")
+	require.NoError(t, err)
+	_, err = cache.compileRegex("b~=/b/", "b")
+	require.NoError(t, err)
+	// Touch "a" so "b" becomes the least recently used entry.
+	_, err = cache.compileRegex("a~=/a/", "a")
+	require.NoError(t, err)
+	_, err = cache.compileRegex("c~=/c/", "c")
+	require.NoError(t, err)
+
+	_, aCached := cache.get("a~=/a/")
+	_, bCached := cache.get("b~=/b/")
+	_, cCached := cache.get("c~=/c/")
+	assert.True(t, aCached)
+	assert.False(t, bCached)
+	assert.True(t, cCached)
+}
+
+func TestMatcherCache_RepeatedSelectCandidatesCalls_ReuseCachedCompile(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model~=/^gpt-4.*/")),
+	}
+	body := []byte(`{"model":"gpt-4-turbo"}`)
+
+	for i := 0; i < 5; i++ {
+		got := selectCandidates(providers, "llm", body, fallbackWildcard)
+		assert.Equal(t, []string{"peer-a"}, got)
+	}
+
+	_, cached := globalMatcherCache.get("model~=/^gpt-4.*/")
+	assert.True(t, cached)
+}
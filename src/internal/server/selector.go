@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"opentela/internal/protocol"
+)
+
+// Selector orders a set of already-eligible candidates for a request. The
+// fallback-level tiering in selectCandidates decides *which* peers are
+// eligible; a Selector only decides the order the caller should try them
+// in, so its output is always a permutation of the IDs in candidates.
+type Selector interface {
+	Rank(ctx context.Context, candidates []protocol.Peer, service string, body []byte) []string
+}
+
+// SelectAndRank is the request-handler entry point: it narrows providers
+// down to the peers eligible for service at fallbackLevel via
+// selectCandidates, then hands that eligible set to selector for ordering.
+// selectCandidates itself keeps returning the plain eligible-ID slice, so
+// callers that don't care about ordering can keep using it directly.
+func SelectAndRank(providers []protocol.Peer, service string, body []byte, fallbackLevel int, selector Selector) []string {
+	eligible := selectCandidates(providers, service, body, fallbackLevel)
+	if len(eligible) == 0 {
+		return nil
+	}
+
+	eligibleSet := make(map[string]bool, len(eligible))
+	for _, id := range eligible {
+		eligibleSet[id] = true
+	}
+	candidates := make([]protocol.Peer, 0, len(eligible))
+	for _, p := range providers {
+		if eligibleSet[p.ID] {
+			candidates = append(candidates, p)
+		}
+	}
+
+	return selector.Rank(context.Background(), candidates, service, body)
+}
+
+func peerIDs(candidates []protocol.Peer) []string {
+	ids := make([]string, len(candidates))
+	for i, p := range candidates {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// RoundRobinSelector cycles through candidates in the order they're given,
+// advancing one step per call so repeated requests spread across peers.
+type RoundRobinSelector struct {
+	next uint64
+}
+
+func (s *RoundRobinSelector) Rank(_ context.Context, candidates []protocol.Peer, _ string, _ []byte) []string {
+	ids := peerIDs(candidates)
+	if len(ids) == 0 {
+		return ids
+	}
+	offset := int(atomic.AddUint64(&s.next, 1)-1) % len(ids)
+	return append(append([]string{}, ids[offset:]...), ids[:offset]...)
+}
+
+// RandomSelector shuffles candidates independently on every call.
+type RandomSelector struct{}
+
+func (RandomSelector) Rank(_ context.Context, candidates []protocol.Peer, _ string, _ []byte) []string {
+	ids := peerIDs(candidates)
+	rand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+	return ids
+}
+
+// defaultWeight is used when a peer advertises no "weight=N" hint, or the
+// hint can't be parsed as a positive integer, for the matched service.
+const defaultWeight = 1
+
+// weightOf returns the weight a peer advertises for service via a
+// "weight=N" identity-group entry, or defaultWeight if it advertises none.
+func weightOf(p protocol.Peer, service string) int {
+	for _, svc := range p.Service {
+		if svc.Name != service {
+			continue
+		}
+		for _, raw := range svc.IdentityGroup {
+			if strings.HasPrefix(raw, weightHintPrefix) {
+				if w, err := strconv.Atoi(raw[len(weightHintPrefix):]); err == nil && w > 0 {
+					return w
+				}
+			}
+		}
+	}
+	return defaultWeight
+}
+
+// WeightedSelector orders candidates by descending "weight=N" hint, so
+// operators can bias load toward higher-capacity peers. Peers with equal
+// weight (including the default) are broken by ID for deterministic
+// ordering across calls.
+type WeightedSelector struct{}
+
+func (WeightedSelector) Rank(_ context.Context, candidates []protocol.Peer, service string, _ []byte) []string {
+	ranked := append([]protocol.Peer{}, candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		wi, wj := weightOf(ranked[i], service), weightOf(ranked[j], service)
+		if wi != wj {
+			return wi > wj
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	return peerIDs(ranked)
+}
+
+// LeastOutstandingSelector ranks candidates by fewest in-flight requests,
+// so a burst of traffic spreads toward peers that are currently idle. The
+// request path is expected to call Begin when it dispatches to a peer and
+// End when that request completes.
+type LeastOutstandingSelector struct {
+	outstanding sync.Map // peer ID (string) -> *int64
+}
+
+func (s *LeastOutstandingSelector) counter(peerID string) *int64 {
+	v, _ := s.outstanding.LoadOrStore(peerID, new(int64))
+	return v.(*int64)
+}
+
+// Begin records that a request was just dispatched to peerID.
+func (s *LeastOutstandingSelector) Begin(peerID string) {
+	atomic.AddInt64(s.counter(peerID), 1)
+}
+
+// End records that a request previously started with Begin has finished.
+func (s *LeastOutstandingSelector) End(peerID string) {
+	atomic.AddInt64(s.counter(peerID), -1)
+}
+
+func (s *LeastOutstandingSelector) Rank(_ context.Context, candidates []protocol.Peer, _ string, _ []byte) []string {
+	ranked := append([]protocol.Peer{}, candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		oi := atomic.LoadInt64(s.counter(ranked[i].ID))
+		oj := atomic.LoadInt64(s.counter(ranked[j].ID))
+		if oi != oj {
+			return oi < oj
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	return peerIDs(ranked)
+}
+
+// PeerHealth reports a health score for a peer, higher is healthier.
+// HealthAwareSelector consults it to rank candidates; the request path is
+// expected to call Record after every completed request so the score
+// reflects recent behavior.
+type PeerHealth interface {
+	// Score returns the current health score for peerID. Peers with no
+	// recorded history should score as healthy, so newly-seen peers aren't
+	// starved in favor of established ones.
+	Score(peerID string) float64
+	// Record updates the oracle with the outcome of a request to peerID.
+	Record(peerID string, latency float64, success bool)
+}
+
+// ewmaAlpha weights how quickly EWMAPeerHealth reacts to new samples
+// versus its existing history.
+const ewmaAlpha = 0.2
+
+// healthySuccessRate is the score assumed for a peer with no recorded
+// samples yet.
+const healthySuccessRate = 1.0
+
+type peerHealthSample struct {
+	latencyEWMA float64
+	successEWMA float64
+}
+
+// EWMAPeerHealth is the default PeerHealth oracle: an exponentially
+// weighted moving average of recent latency and success rate per peer.
+// mu guards reads in Score as well as writes in Record, since the request
+// path calls Record concurrently with HealthAwareSelector.Rank calling
+// Score.
+type EWMAPeerHealth struct {
+	samples sync.Map // peer ID (string) -> *peerHealthSample
+	mu      sync.RWMutex
+}
+
+func (h *EWMAPeerHealth) Record(peerID string, latency float64, success bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	successValue := 0.0
+	if success {
+		successValue = 1.0
+	}
+
+	v, loaded := h.samples.LoadOrStore(peerID, &peerHealthSample{
+		latencyEWMA: latency,
+		successEWMA: successValue,
+	})
+	if !loaded {
+		return
+	}
+	sample := v.(*peerHealthSample)
+	sample.latencyEWMA = ewmaAlpha*latency + (1-ewmaAlpha)*sample.latencyEWMA
+	sample.successEWMA = ewmaAlpha*successValue + (1-ewmaAlpha)*sample.successEWMA
+}
+
+func (h *EWMAPeerHealth) Score(peerID string) float64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	v, ok := h.samples.Load(peerID)
+	if !ok {
+		return healthySuccessRate
+	}
+	sample := v.(*peerHealthSample)
+	// Success rate dominates the score; latency only breaks ties among
+	// peers with comparable reliability.
+	return sample.successEWMA - sample.latencyEWMA/1000
+}
+
+// HealthAwareSelector ranks candidates by descending health score.
+type HealthAwareSelector struct {
+	Health PeerHealth
+}
+
+func (s HealthAwareSelector) Rank(_ context.Context, candidates []protocol.Peer, _ string, _ []byte) []string {
+	ranked := append([]protocol.Peer{}, candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		si, sj := s.Health.Score(ranked[i].ID), s.Health.Score(ranked[j].ID)
+		if si != sj {
+			return si > sj
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	return peerIDs(ranked)
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"opentela/internal/protocol"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ---------------------------------------------------------------------------
+// WeightedSelector
+// ---------------------------------------------------------------------------
+
+func TestWeightedSelector_OrdersByDescendingWeight(t *testing.T) {
+	candidates := []protocol.Peer{
+		peer("peer-light", svc("llm", "model=gpt4", "weight=1")),
+		peer("peer-heavy", svc("llm", "model=gpt4", "weight=9")),
+		peer("peer-mid", svc("llm", "model=gpt4", "weight=5")),
+	}
+	got := WeightedSelector{}.Rank(context.Background(), candidates, "llm", nil)
+	assert.Equal(t, []string{"peer-heavy", "peer-mid", "peer-light"}, got)
+}
+
+func TestWeightedSelector_MissingWeight_DefaultsToOne(t *testing.T) {
+	candidates := []protocol.Peer{
+		peer("peer-default", svc("llm", "model=gpt4")),
+		peer("peer-heavy", svc("llm", "model=gpt4", "weight=3")),
+	}
+	got := WeightedSelector{}.Rank(context.Background(), candidates, "llm", nil)
+	assert.Equal(t, []string{"peer-heavy", "peer-default"}, got)
+}
+
+func TestWeightedSelector_EqualWeight_TieBreaksByID(t *testing.T) {
+	candidates := []protocol.Peer{
+		peer("peer-b", svc("llm", "model=gpt4", "weight=4")),
+		peer("peer-a", svc("llm", "model=gpt4", "weight=4")),
+	}
+	got := WeightedSelector{}.Rank(context.Background(), candidates, "llm", nil)
+	assert.Equal(t, []string{"peer-a", "peer-b"}, got)
+}
+
+// ---------------------------------------------------------------------------
+// LeastOutstandingSelector
+// ---------------------------------------------------------------------------
+
+func TestLeastOutstandingSelector_PrefersFewerInFlight(t *testing.T) {
+	selector := &LeastOutstandingSelector{}
+	candidates := []protocol.Peer{
+		peer("peer-busy"),
+		peer("peer-idle"),
+	}
+	selector.Begin("peer-busy")
+	selector.Begin("peer-busy")
+	selector.Begin("peer-idle")
+
+	got := selector.Rank(context.Background(), candidates, "llm", nil)
+	assert.Equal(t, []string{"peer-idle", "peer-busy"}, got)
+}
+
+func TestLeastOutstandingSelector_EndRestoresOrdering(t *testing.T) {
+	selector := &LeastOutstandingSelector{}
+	candidates := []protocol.Peer{
+		peer("peer-a"),
+		peer("peer-b"),
+	}
+	selector.Begin("peer-a")
+	selector.End("peer-a")
+
+	// Both peers are back to zero in-flight requests; tie-break by ID.
+	got := selector.Rank(context.Background(), candidates, "llm", nil)
+	assert.Equal(t, []string{"peer-a", "peer-b"}, got)
+}
+
+// ---------------------------------------------------------------------------
+// HealthAwareSelector
+// ---------------------------------------------------------------------------
+
+func TestHealthAwareSelector_PrefersHigherScore(t *testing.T) {
+	health := &EWMAPeerHealth{}
+	health.Record("peer-unhealthy", 50, false)
+	health.Record("peer-healthy", 50, true)
+
+	selector := HealthAwareSelector{Health: health}
+	candidates := []protocol.Peer{
+		peer("peer-unhealthy"),
+		peer("peer-healthy"),
+	}
+	got := selector.Rank(context.Background(), candidates, "llm", nil)
+	assert.Equal(t, []string{"peer-healthy", "peer-unhealthy"}, got)
+}
+
+func TestHealthAwareSelector_UnknownPeer_TreatedAsHealthy(t *testing.T) {
+	health := &EWMAPeerHealth{}
+	health.Record("peer-known", 50, false)
+
+	selector := HealthAwareSelector{Health: health}
+	candidates := []protocol.Peer{
+		peer("peer-known"),
+		peer("peer-new"),
+	}
+	got := selector.Rank(context.Background(), candidates, "llm", nil)
+	assert.Equal(t, []string{"peer-new", "peer-known"}, got)
+}
+
+// ---------------------------------------------------------------------------
+// RoundRobinSelector / RandomSelector
+// ---------------------------------------------------------------------------
+
+func TestRoundRobinSelector_AdvancesEachCall(t *testing.T) {
+	selector := &RoundRobinSelector{}
+	candidates := []protocol.Peer{peer("peer-a"), peer("peer-b"), peer("peer-c")}
+
+	first := selector.Rank(context.Background(), candidates, "llm", nil)
+	second := selector.Rank(context.Background(), candidates, "llm", nil)
+
+	assert.Equal(t, []string{"peer-a", "peer-b", "peer-c"}, first)
+	assert.Equal(t, []string{"peer-b", "peer-c", "peer-a"}, second)
+}
+
+func TestRandomSelector_ReturnsPermutationOfCandidates(t *testing.T) {
+	candidates := []protocol.Peer{peer("peer-a"), peer("peer-b"), peer("peer-c")}
+	got := RandomSelector{}.Rank(context.Background(), candidates, "llm", nil)
+	assert.ElementsMatch(t, []string{"peer-a", "peer-b", "peer-c"}, got)
+}
+
+// ---------------------------------------------------------------------------
+// SelectAndRank
+// ---------------------------------------------------------------------------
+
+func TestSelectAndRank_NarrowsToEligibleTierThenRanks(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-exact-heavy", svc("llm", "model=gpt4", "weight=5")),
+		peer("peer-exact-light", svc("llm", "model=gpt4", "weight=1")),
+		peer("peer-wildcard", svc("llm", "model=*")),
+	}
+	body := []byte(`{"model":"gpt4"}`)
+	got := SelectAndRank(providers, "llm", body, 2, WeightedSelector{})
+	// Exact tier wins over wildcard regardless of weight, then weight orders within it.
+	assert.Equal(t, []string{"peer-exact-heavy", "peer-exact-light"}, got)
+}
+
+func TestSelectAndRank_NoEligiblePeers_ReturnsEmpty(t *testing.T) {
+	providers := []protocol.Peer{
+		peer("peer-a", svc("llm", "model=llama")),
+	}
+	got := SelectAndRank(providers, "llm", []byte(`{"model":"gpt4"}`), 0, WeightedSelector{})
+	assert.Empty(t, got)
+}